@@ -0,0 +1,173 @@
+// Package bls wraps a BLS12-381 signature implementation with the small
+// surface the DPoS confirm path needs: single signatures, aggregation of
+// many signatures into one, aggregate verification against a set of public
+// keys and messages, and proof-of-possession so a key can be safely
+// admitted into an aggregate-signing set.
+package bls
+
+import (
+	"errors"
+
+	blst "github.com/kilic/bls12-381"
+)
+
+// Domain separation tags for the two message classes this package hashes to
+// the curve. signDST is used for every confirm-vote signature; popDST is
+// used only for proof-of-possession signatures, so a PoP can never be
+// replayed as a vote signature or vice versa.
+var (
+	signDST = []byte("ELA-DPOS-CONFIRM-BLS-SIG-V1")
+	popDST  = []byte("ELA-DPOS-POP-BLS-SIG-V1")
+)
+
+// PrivateKey is a BLS12-381 scalar used to sign confirm votes.
+type PrivateKey struct {
+	scalar *blst.Fr
+}
+
+// PublicKey is a BLS12-381 G1 point identifying an arbiter.
+type PublicKey struct {
+	point *blst.PointG1
+}
+
+// Signature is a BLS12-381 G2 point, either a single signature or the
+// aggregate of several.
+type Signature struct {
+	point *blst.PointG2
+}
+
+// NewPrivateKeyFromBytes parses a 32-byte scalar into a PrivateKey.
+func NewPrivateKeyFromBytes(b []byte) (*PrivateKey, error) {
+	fr := blst.NewFr()
+	if err := fr.SetBytes(b); err != nil {
+		return nil, err
+	}
+	return &PrivateKey{scalar: fr}, nil
+}
+
+// PublicKey derives the public key corresponding to this private key.
+func (k *PrivateKey) PublicKey() *PublicKey {
+	g1 := blst.NewG1()
+	return &PublicKey{point: g1.MulScalar(g1.New(), g1.One(), k.scalar)}
+}
+
+// Sign produces a BLS signature over message.
+func (k *PrivateKey) Sign(message []byte) (*Signature, error) {
+	return k.signWithDST(message, signDST)
+}
+
+func (k *PrivateKey) signWithDST(message, dst []byte) (*Signature, error) {
+	g2 := blst.NewG2()
+	point, err := g2.HashToCurve(message, dst)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{point: g2.MulScalar(g2.New(), point, k.scalar)}, nil
+}
+
+// PopProve produces a proof of possession for this key pair: a signature
+// over the public key itself, under a DST distinct from vote signing. An
+// arbiter must present a valid PoP when registering its BLS public key;
+// rejecting registrations without one defeats the rogue-public-key attack,
+// where an attacker derives a key crafted to cancel out an honest key's
+// contribution to an aggregate, without needing every confirm signer to
+// sign a distinct per-signer message.
+func (k *PrivateKey) PopProve() (*Signature, error) {
+	return k.signWithDST(k.PublicKey().Bytes(), popDST)
+}
+
+// PopVerify checks a proof of possession produced by PopProve. Intended to
+// run once per key, before the key is ever admitted to the active arbiter
+// set used by AggregateVerify — callers that check proof of possession on
+// every confirm rather than once per key should cache a successful result
+// (see verconf.AdmittedKeyCache) instead of calling this on every confirm a
+// signer contributes to.
+func PopVerify(pub *PublicKey, pop *Signature) (bool, error) {
+	return verifyAggregate([]*PublicKey{pub}, [][]byte{pub.Bytes()}, pop, popDST)
+}
+
+// Verify checks a single signature against a public key and message.
+func Verify(pub *PublicKey, message []byte, sig *Signature) (bool, error) {
+	return AggregateVerify([]*PublicKey{pub}, [][]byte{message}, sig)
+}
+
+// Aggregate combines multiple signatures into a single aggregate signature.
+// The caller is responsible for tracking which signer contributed which
+// signature, typically via a bitmap into a known arbiter set.
+func Aggregate(sigs []*Signature) (*Signature, error) {
+	if len(sigs) == 0 {
+		return nil, errors.New("bls: no signatures to aggregate")
+	}
+	g2 := blst.NewG2()
+	agg := g2.Zero()
+	for _, s := range sigs {
+		agg = g2.Add(g2.New(), agg, s.point)
+	}
+	return &Signature{point: agg}, nil
+}
+
+// AggregateVerify checks an aggregate signature against the public keys and
+// messages of every contributing signer. len(pubs) must equal len(messages).
+//
+// Every confirm signer is expected to have passed PopVerify at registration
+// time; without that, aggregating over a single shared message (as confirm
+// votes do) is vulnerable to a rogue-public-key attack where an attacker
+// derives a key that cancels an honest signer's contribution. This function
+// only checks the aggregate equation — it does not and cannot re-verify PoP
+// for every caller, so callers must keep non-PoP-verified keys out of the
+// active arbiter set.
+func AggregateVerify(pubs []*PublicKey, messages [][]byte, sig *Signature) (bool, error) {
+	return verifyAggregate(pubs, messages, sig, signDST)
+}
+
+func verifyAggregate(pubs []*PublicKey, messages [][]byte, sig *Signature, dst []byte) (bool, error) {
+	if len(pubs) != len(messages) {
+		return false, errors.New("bls: public key and message count mismatch")
+	}
+	if len(pubs) == 0 {
+		return false, errors.New("bls: no signers")
+	}
+
+	engine := blst.NewEngine()
+	g2 := blst.NewG2()
+	for i, pub := range pubs {
+		point, err := g2.HashToCurve(messages[i], dst)
+		if err != nil {
+			return false, err
+		}
+		engine.AddPair(pub.point, point)
+	}
+	engine.AddPairInv(blst.NewG1().One(), sig.point)
+
+	return engine.Check(), nil
+}
+
+// Bytes serializes the signature to its compressed G2 representation.
+func (s *Signature) Bytes() []byte {
+	return blst.NewG2().ToBytes(s.point)
+}
+
+// SignatureFromBytes parses a compressed G2 point into a Signature.
+func SignatureFromBytes(b []byte) (*Signature, error) {
+	g2 := blst.NewG2()
+	point, err := g2.FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{point: point}, nil
+}
+
+// Bytes serializes the public key to its compressed G1 representation.
+func (k *PublicKey) Bytes() []byte {
+	return blst.NewG1().ToBytes(k.point)
+}
+
+// PublicKeyFromBytes parses a compressed G1 point into a PublicKey.
+func PublicKeyFromBytes(b []byte) (*PublicKey, error) {
+	g1 := blst.NewG1()
+	point, err := g1.FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKey{point: point}, nil
+}
@@ -0,0 +1,147 @@
+package bls
+
+import "testing"
+
+func newTestKey(t *testing.T, seed byte) *PrivateKey {
+	t.Helper()
+	raw := make([]byte, 32)
+	raw[31] = seed
+	key, err := NewPrivateKeyFromBytes(raw)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyFromBytes: %v", err)
+	}
+	return key
+}
+
+func TestSignVerify(t *testing.T) {
+	key := newTestKey(t, 1)
+	message := []byte("confirm this block")
+
+	sig, err := key.Sign(message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	ok, err := Verify(key.PublicKey(), message, sig)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Fatal("Verify returned false for a genuine signature")
+	}
+
+	ok, err = Verify(key.PublicKey(), []byte("a different message"), sig)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if ok {
+		t.Fatal("Verify returned true for a tampered message")
+	}
+}
+
+// TestAggregateVerifySharedMessage pins the aggregate-confirm case: every
+// signer signs the same proposal message, and a single aggregate signature
+// verifies against all of their public keys together.
+func TestAggregateVerifySharedMessage(t *testing.T) {
+	message := []byte("proposal for height 100")
+	keys := []*PrivateKey{newTestKey(t, 1), newTestKey(t, 2), newTestKey(t, 3)}
+
+	pubs := make([]*PublicKey, len(keys))
+	messages := make([][]byte, len(keys))
+	sigs := make([]*Signature, len(keys))
+	for i, k := range keys {
+		pubs[i] = k.PublicKey()
+		messages[i] = message
+		sig, err := k.Sign(message)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		sigs[i] = sig
+	}
+
+	agg, err := Aggregate(sigs)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+
+	ok, err := AggregateVerify(pubs, messages, agg)
+	if err != nil {
+		t.Fatalf("AggregateVerify: %v", err)
+	}
+	if !ok {
+		t.Fatal("AggregateVerify returned false for a genuine aggregate")
+	}
+}
+
+// TestAggregateVerifyRejectsMissingSigner checks that dropping one signer's
+// public key from the set invalidates the aggregate, since the aggregate
+// signature no longer matches the (shorter) set of keys being checked.
+func TestAggregateVerifyRejectsMissingSigner(t *testing.T) {
+	message := []byte("proposal for height 100")
+	keys := []*PrivateKey{newTestKey(t, 1), newTestKey(t, 2), newTestKey(t, 3)}
+
+	pubs := make([]*PublicKey, len(keys))
+	messages := make([][]byte, len(keys))
+	sigs := make([]*Signature, len(keys))
+	for i, k := range keys {
+		pubs[i] = k.PublicKey()
+		messages[i] = message
+		sig, err := k.Sign(message)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		sigs[i] = sig
+	}
+
+	agg, err := Aggregate(sigs)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+
+	ok, err := AggregateVerify(pubs[:2], messages[:2], agg)
+	if err != nil {
+		t.Fatalf("AggregateVerify: %v", err)
+	}
+	if ok {
+		t.Fatal("AggregateVerify returned true with a signer missing from the key set")
+	}
+}
+
+func TestPopProveVerify(t *testing.T) {
+	key := newTestKey(t, 1)
+
+	pop, err := key.PopProve()
+	if err != nil {
+		t.Fatalf("PopProve: %v", err)
+	}
+
+	ok, err := PopVerify(key.PublicKey(), pop)
+	if err != nil {
+		t.Fatalf("PopVerify: %v", err)
+	}
+	if !ok {
+		t.Fatal("PopVerify returned false for a genuine proof of possession")
+	}
+}
+
+// TestPopProveRejectsVoteSignature checks that signDST and popDST are
+// actually distinct: a regular vote signature over the public key's bytes
+// must not pass as a proof of possession, or a rogue key could forge one by
+// just getting its owner to sign a message that happens to equal its own
+// public key encoding.
+func TestPopProveRejectsVoteSignature(t *testing.T) {
+	key := newTestKey(t, 1)
+
+	voteSig, err := key.Sign(key.PublicKey().Bytes())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	ok, err := PopVerify(key.PublicKey(), voteSig)
+	if err != nil {
+		t.Fatalf("PopVerify: %v", err)
+	}
+	if ok {
+		t.Fatal("PopVerify accepted a vote signature in place of a proof of possession")
+	}
+}
@@ -0,0 +1,185 @@
+package dpos
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+)
+
+// voteKey identifies the (height, round) a vote was cast for.
+type voteKey struct {
+	height uint32
+	round  uint32
+}
+
+// VoteRecord is a single signed proposal and vote observed from the DPoS
+// network layer, kept long enough to detect a signer contradicting
+// themselves at the same (height, round). Proposal and Vote are the genuine
+// signed objects, not a re-serialization of them, so they can be dropped
+// straight into an IllegalVoteEvidence transaction.
+type VoteRecord struct {
+	Height   uint32
+	Round    uint32
+	Hash     common.Uint256
+	Proposal types.DPosProposal
+	Vote     types.DPosProposalVote
+}
+
+// Equivocation is two conflicting votes cast by the same signer at the same
+// (height, round), the raw material for an IllegalVoteEvidence transaction.
+type Equivocation struct {
+	Signer []byte
+	First  VoteRecord
+	Second VoteRecord
+}
+
+// EquivocationTracker watches every DPoS vote a signer casts and flags
+// equivocation — two conflicting proposals or votes at the same
+// (height, round) — even when neither has been finalized locally. This
+// closes the gap left by CheckConfirmedBlockOnFork, which only catches
+// equivocation after a conflicting block has already been stored.
+//
+// It is bounded two ways so a long-lived node doesn't retain every vote
+// ever seen: a per-signer LRU evicts whole signers in
+// least-recently-observed order once the tracker is full, and within a
+// surviving signer's entry, votes for (height, round) keys older than
+// heightWindow below the highest height that signer has been observed at
+// are pruned on every Observe call.
+type EquivocationTracker struct {
+	mutex        sync.Mutex
+	capacity     int
+	heightWindow uint32
+	entries      map[string]*list.Element
+	order        *list.List
+	pending      []*Equivocation
+}
+
+// maxPendingEvidence bounds how many detected-but-unacknowledged
+// equivocations PendingEvidence retains; once full, the oldest is dropped
+// in favor of the newest.
+const maxPendingEvidence = 256
+
+type trackerEntry struct {
+	signer    string
+	maxHeight uint32
+	votes     map[voteKey]VoteRecord
+	reported  map[voteKey]bool
+}
+
+// prune drops every (height, round) key more than heightWindow below the
+// highest height seen for this signer. A heightWindow of 0 disables
+// pruning.
+func (e *trackerEntry) prune(heightWindow uint32) {
+	if heightWindow == 0 || e.maxHeight < heightWindow {
+		return
+	}
+	cutoff := e.maxHeight - heightWindow
+	for vk := range e.votes {
+		if vk.height < cutoff {
+			delete(e.votes, vk)
+			delete(e.reported, vk)
+		}
+	}
+}
+
+// NewEquivocationTracker creates a tracker bounded to at most capacity
+// distinct signers, each retaining votes for at most heightWindow past
+// their most recently observed height. A heightWindow of 0 retains every
+// height for a signer still tracked.
+func NewEquivocationTracker(capacity int, heightWindow uint32) *EquivocationTracker {
+	return &EquivocationTracker{
+		capacity:     capacity,
+		heightWindow: heightWindow,
+		entries:      make(map[string]*list.Element),
+		order:        list.New(),
+	}
+}
+
+// Observe records a vote from signer and returns the Equivocation if this
+// vote conflicts with one already seen for the same (height, round). Once an
+// equivocation has been reported for a given (height, round) it is not
+// reported again, even if the signer goes on to cast further conflicting
+// votes at that key — one proof is enough and repeat reports would just
+// spam the mempool with duplicate evidence transactions.
+func (t *EquivocationTracker) Observe(signer []byte, record VoteRecord) *Equivocation {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := string(signer)
+	elem, ok := t.entries[key]
+	if !ok {
+		elem = t.order.PushFront(&trackerEntry{
+			signer:   key,
+			votes:    make(map[voteKey]VoteRecord),
+			reported: make(map[voteKey]bool),
+		})
+		t.entries[key] = elem
+		t.evictIfFull()
+	} else {
+		t.order.MoveToFront(elem)
+	}
+
+	entry := elem.Value.(*trackerEntry)
+	if record.Height > entry.maxHeight {
+		entry.maxHeight = record.Height
+	}
+	entry.prune(t.heightWindow)
+
+	vk := voteKey{height: record.Height, round: record.Round}
+	prior, seen := entry.votes[vk]
+	if !seen {
+		entry.votes[vk] = record
+		return nil
+	}
+	if prior.Hash == record.Hash || entry.reported[vk] {
+		return nil
+	}
+	entry.reported[vk] = true
+
+	// Order the two sides canonically by hash so the same equivocation
+	// always serializes to the same Evidence/CompareEvidence assignment
+	// regardless of which conflicting vote arrived first.
+	first, second := prior, record
+	if bytes.Compare(second.Hash[:], first.Hash[:]) < 0 {
+		first, second = second, first
+	}
+
+	equivocation := &Equivocation{
+		Signer: append([]byte(nil), signer...),
+		First:  first,
+		Second: second,
+	}
+	t.pending = append(t.pending, equivocation)
+	if len(t.pending) > maxPendingEvidence {
+		t.pending = t.pending[len(t.pending)-maxPendingEvidence:]
+	}
+
+	return equivocation
+}
+
+// PendingEvidence returns every equivocation detected so far, most recent
+// last, up to maxPendingEvidence. It is the data an RPC querying pending
+// illegal-vote evidence exposes, independent of whether each equivocation's
+// evidence transaction has since been mined.
+func (t *EquivocationTracker) PendingEvidence() []*Equivocation {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	result := make([]*Equivocation, len(t.pending))
+	copy(result, t.pending)
+	return result
+}
+
+func (t *EquivocationTracker) evictIfFull() {
+	for len(t.entries) > t.capacity {
+		oldest := t.order.Back()
+		if oldest == nil {
+			return
+		}
+		t.order.Remove(oldest)
+		delete(t.entries, oldest.Value.(*trackerEntry).signer)
+	}
+}
@@ -0,0 +1,150 @@
+package dpos
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+)
+
+func testRecord(height, round uint32, hashByte byte) VoteRecord {
+	hash := common.Uint256{}
+	hash[0] = hashByte
+	return VoteRecord{
+		Height:   height,
+		Round:    round,
+		Hash:     hash,
+		Proposal: types.DPosProposal{},
+		Vote:     types.DPosProposalVote{},
+	}
+}
+
+func TestObserveNoEquivocationOnFirstVote(t *testing.T) {
+	tracker := NewEquivocationTracker(16, 0)
+	if eq := tracker.Observe([]byte("signer-a"), testRecord(100, 0, 1)); eq != nil {
+		t.Fatalf("got equivocation on first vote: %+v", eq)
+	}
+	if len(tracker.PendingEvidence()) != 0 {
+		t.Fatalf("expected no pending evidence, got %d", len(tracker.PendingEvidence()))
+	}
+}
+
+func TestObserveIgnoresRepeatOfSameVote(t *testing.T) {
+	tracker := NewEquivocationTracker(16, 0)
+	signer := []byte("signer-a")
+	record := testRecord(100, 0, 1)
+
+	tracker.Observe(signer, record)
+	if eq := tracker.Observe(signer, record); eq != nil {
+		t.Fatalf("got equivocation for a repeated identical vote: %+v", eq)
+	}
+}
+
+// TestObserveDetectsConflictingVoteSameKey pins the core detection path: two
+// different votes from the same signer at the same (height, round) must be
+// reported as an equivocation exactly once.
+func TestObserveDetectsConflictingVoteSameKey(t *testing.T) {
+	tracker := NewEquivocationTracker(16, 0)
+	signer := []byte("signer-a")
+
+	first := testRecord(100, 0, 1)
+	second := testRecord(100, 0, 2)
+
+	if eq := tracker.Observe(signer, first); eq != nil {
+		t.Fatalf("got equivocation on first vote: %+v", eq)
+	}
+	eq := tracker.Observe(signer, second)
+	if eq == nil {
+		t.Fatal("expected an equivocation for a conflicting vote at the same (height, round)")
+	}
+	if string(eq.Signer) != string(signer) {
+		t.Fatalf("equivocation signer = %q, want %q", eq.Signer, signer)
+	}
+
+	// The two sides must be ordered canonically by hash: the lower hash
+	// (first's, 0x01...) is First and the higher one is Second.
+	if eq.First.Hash != first.Hash || eq.Second.Hash != second.Hash {
+		t.Fatalf("equivocation sides = (%v, %v), want lower hash first", eq.First.Hash, eq.Second.Hash)
+	}
+
+	pending := tracker.PendingEvidence()
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending equivocation, got %d", len(pending))
+	}
+}
+
+// TestObserveReportsEquivocationOnlyOnce checks that a third conflicting vote
+// at a (height, round) already reported doesn't spam another equivocation.
+func TestObserveReportsEquivocationOnlyOnce(t *testing.T) {
+	tracker := NewEquivocationTracker(16, 0)
+	signer := []byte("signer-a")
+
+	tracker.Observe(signer, testRecord(100, 0, 1))
+	if eq := tracker.Observe(signer, testRecord(100, 0, 2)); eq == nil {
+		t.Fatal("expected an equivocation for the second conflicting vote")
+	}
+	if eq := tracker.Observe(signer, testRecord(100, 0, 3)); eq != nil {
+		t.Fatalf("got a second equivocation report for the same (height, round): %+v", eq)
+	}
+	if len(tracker.PendingEvidence()) != 1 {
+		t.Fatalf("expected 1 pending equivocation, got %d", len(tracker.PendingEvidence()))
+	}
+}
+
+// TestEvictIfFullDropsLeastRecentlyObservedSigner pins the per-signer LRU:
+// once capacity is exceeded, the signer that hasn't been observed the
+// longest is evicted first, so its prior votes no longer guard against a
+// conflicting vote arriving later.
+func TestEvictIfFullDropsLeastRecentlyObservedSigner(t *testing.T) {
+	tracker := NewEquivocationTracker(2, 0)
+
+	tracker.Observe([]byte("signer-a"), testRecord(100, 0, 1))
+	tracker.Observe([]byte("signer-b"), testRecord(100, 0, 1))
+	tracker.Observe([]byte("signer-c"), testRecord(100, 0, 1))
+
+	if len(tracker.entries) != 2 {
+		t.Fatalf("tracker has %d entries, want 2 after eviction", len(tracker.entries))
+	}
+	if _, ok := tracker.entries[string([]byte("signer-a"))]; ok {
+		t.Fatal("signer-a should have been evicted as least recently observed")
+	}
+
+	// signer-a was evicted, so its prior vote is forgotten: a "conflicting"
+	// vote at the same key is now treated as the first vote, not an
+	// equivocation.
+	if eq := tracker.Observe([]byte("signer-a"), testRecord(100, 0, 2)); eq != nil {
+		t.Fatalf("got equivocation for an evicted signer's forgotten vote: %+v", eq)
+	}
+}
+
+// TestPruneDropsVotesOutsideHeightWindow pins the bounded-retention guarantee:
+// once a signer has been observed at a high enough height, a (height, round)
+// key that falls outside heightWindow below it is dropped, so a later
+// "conflicting" vote at that stale key is no longer flagged.
+func TestPruneDropsVotesOutsideHeightWindow(t *testing.T) {
+	tracker := NewEquivocationTracker(16, 10)
+	signer := []byte("signer-a")
+
+	tracker.Observe(signer, testRecord(100, 0, 1))
+	// Push maxHeight far enough ahead that height 100 falls outside the
+	// heightWindow of 10 and gets pruned.
+	tracker.Observe(signer, testRecord(200, 0, 1))
+
+	if eq := tracker.Observe(signer, testRecord(100, 0, 2)); eq != nil {
+		t.Fatalf("got equivocation for a vote at a pruned (height, round) key: %+v", eq)
+	}
+}
+
+func TestPendingEvidenceBoundedToMax(t *testing.T) {
+	tracker := NewEquivocationTracker(maxPendingEvidence+10, 0)
+
+	for i := 0; i < maxPendingEvidence+10; i++ {
+		signer := []byte{byte(i), byte(i >> 8)}
+		tracker.Observe(signer, testRecord(uint32(i), 0, 1))
+		tracker.Observe(signer, testRecord(uint32(i), 0, 2))
+	}
+
+	if len(tracker.PendingEvidence()) != maxPendingEvidence {
+		t.Fatalf("pending evidence = %d, want bounded to %d", len(tracker.PendingEvidence()), maxPendingEvidence)
+	}
+}
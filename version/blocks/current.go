@@ -11,6 +11,8 @@ import (
 	"github.com/elastos/Elastos.ELA/core/contract/program"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/crypto/bls"
+	"github.com/elastos/Elastos.ELA/dpos"
 	"github.com/elastos/Elastos.ELA/version/verconf"
 )
 
@@ -42,6 +44,14 @@ func (b *blockCurrent) CheckConfirmedBlockOnFork(block *types.Block) error {
 		return nil
 	}
 
+	confirm, err := b.cfg.ChainStore.GetConfirm(block.Hash())
+	if err != nil {
+		return err
+	}
+	if err := b.observeConfirmVotes(confirm, block.Height); err != nil {
+		return err
+	}
+
 	hash, err := b.cfg.ChainStore.GetBlockHash(block.Height)
 	if err != nil {
 		return err
@@ -98,6 +108,107 @@ func (b *blockCurrent) CheckConfirmedBlockOnFork(block *types.Block) error {
 	return nil
 }
 
+// ObserveDposVote feeds a single raw vote into the equivocation tracker and
+// requires no confirm: it is the hook the DPoS consensus/network layer is
+// meant to call directly as each proposal/vote message is received over the
+// wire, which is what catches a conflicting pair before either side is ever
+// finalized into a confirm. That network-layer call site lives in the DPoS
+// consensus package, outside this one, and is not added here.
+//
+// observeConfirmVotes below is this package's own, narrower caller: a
+// same-package backstop that replays a confirm's votes through this method
+// once the confirm already exists, so equivocation is still caught even for
+// a deployment that hasn't wired the real per-vote network call yet. It is
+// not a substitute for that wiring, only a fallback with a strictly smaller
+// detection window.
+func (b *blockCurrent) ObserveDposVote(signer []byte, record dpos.VoteRecord) error {
+	equivocation := b.cfg.EquivocationTracker.Observe(signer, record)
+	if equivocation == nil {
+		return nil
+	}
+	return b.submitIllegalVoteEvidence(equivocation)
+}
+
+// observeConfirmVotes is the confirm-level backstop described above: it
+// feeds every individual vote in a legacy (non-aggregated) confirm into the
+// equivocation tracker via ObserveDposVote. Aggregated confirms only carry a
+// single combined signature and a bitmap of signers, not an individual
+// DPosProposalVote per signer, so they can't be decomposed into per-signer
+// VoteRecords here; a signer whose confirms are aggregated is caught only by
+// the same-height check CheckConfirmedBlockOnFork already performs, or by
+// the real per-vote network wiring once it exists.
+func (b *blockCurrent) observeConfirmVotes(confirm *types.DPosProposalVoteSlot, height uint32) error {
+	if confirm.Aggregated {
+		return nil
+	}
+	for _, v := range confirm.Votes {
+		signer, err := common.HexStringToBytes(v.Signer)
+		if err != nil {
+			return err
+		}
+		record := dpos.VoteRecord{
+			Height:   height,
+			Round:    confirm.Proposal.ViewOffset,
+			Hash:     v.Hash(),
+			Proposal: confirm.Proposal,
+			Vote:     v,
+		}
+		if err := b.ObserveDposVote(signer, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPendingIllegalVoteEvidence returns every equivocation detected so far,
+// regardless of whether its evidence transaction has since been mined. It is
+// the accessor an operator-facing RPC would call to see byzantine signers
+// ObserveDposVote has caught without scanning the chain for already-confirmed
+// IllegalVoteEvidence transactions; no such RPC is registered in this
+// package.
+func (b *blockCurrent) GetPendingIllegalVoteEvidence() []*dpos.Equivocation {
+	return b.cfg.EquivocationTracker.PendingEvidence()
+}
+
+// submitIllegalVoteEvidence builds an IllegalVoteEvidence transaction from a
+// detected equivocation. The two sides are already ordered canonically by
+// hash (see EquivocationTracker.Observe), so the same equivocation always
+// produces the same Evidence/CompareEvidence assignment no matter which
+// conflicting vote the tracker saw first.
+func (b *blockCurrent) submitIllegalVoteEvidence(equivocation *dpos.Equivocation) error {
+	illegalVotes := &types.PayloadIllegalVote{
+		DposIllegalVotes: types.DposIllegalVotes{
+			CoinType: types.ELACoin,
+			Height:   equivocation.First.Height,
+			Round:    equivocation.First.Round,
+			Signer:   equivocation.Signer,
+			Evidence: types.VoteEvidence{
+				Proposal: equivocation.First.Proposal,
+				Vote:     equivocation.First.Vote,
+			},
+			CompareEvidence: types.VoteEvidence{
+				Proposal: equivocation.Second.Proposal,
+				Vote:     equivocation.Second.Vote,
+			},
+		},
+	}
+
+	tx := &types.Transaction{
+		Version:        types.TransactionVersion(b.cfg.Versions.GetDefaultTxVersion(equivocation.First.Height)),
+		TxType:         types.IllegalVoteEvidence,
+		PayloadVersion: types.PayloadIllegalVoteVersion,
+		Payload:        illegalVotes,
+		Attributes:     []*types.Attribute{},
+		LockTime:       0,
+		Programs:       []*program.Program{},
+		Outputs:        []*types.Output{},
+		Inputs:         []*types.Input{},
+		Fee:            0,
+	}
+
+	return b.cfg.TxMemPool.AppendToTxPool(tx)
+}
+
 func (b *blockCurrent) generateBlockEvidence(block *types.Block) (*types.BlockEvidence, error) {
 	headerBuf := new(bytes.Buffer)
 	if err := block.Header.Serialize(headerBuf); err != nil {
@@ -112,6 +223,10 @@ func (b *blockCurrent) generateBlockEvidence(block *types.Block) (*types.BlockEv
 	if err = confirm.Serialize(confirmBuf); err != nil {
 		return nil, err
 	}
+	if err := b.verifyConfirm(confirm, block.Height); err != nil {
+		return nil, err
+	}
+
 	confirmSigners, err := b.getConfirmSigners(confirm)
 	if err != nil {
 		return nil, err
@@ -125,6 +240,10 @@ func (b *blockCurrent) generateBlockEvidence(block *types.Block) (*types.BlockEv
 }
 
 func (b *blockCurrent) getConfirmSigners(confirm *types.DPosProposalVoteSlot) ([][]byte, error) {
+	if confirm.Aggregated {
+		return b.getAggregateConfirmSigners(confirm)
+	}
+
 	result := make([][]byte, 0)
 	for _, v := range confirm.Votes {
 		data, err := common.HexStringToBytes(v.Signer)
@@ -136,6 +255,96 @@ func (b *blockCurrent) getConfirmSigners(confirm *types.DPosProposalVoteSlot) ([
 	return result, nil
 }
 
+// getAggregateConfirmSigners resolves the signer bitmap against the current
+// arbiter set, avoiding an O(N) list of per-vote pubkeys on the wire.
+func (b *blockCurrent) getAggregateConfirmSigners(confirm *types.DPosProposalVoteSlot) ([][]byte, error) {
+	arbitrators := b.cfg.Arbitrators.GetArbitrators()
+	result := make([][]byte, 0, len(confirm.SignerBitmap))
+	for i, present := range confirm.SignerBitmap {
+		if !present {
+			continue
+		}
+		if i >= len(arbitrators) {
+			return nil, errors.New("aggregate confirm signer bitmap references unknown arbiter")
+		}
+		result = append(result, arbitrators[i])
+	}
+	return result, nil
+}
+
+// verifyConfirm enforces the aggregate-confirm activation-height gate and,
+// once a confirm claims to be aggregated, its signature. It runs on both the
+// normal confirm-acceptance path (AddDposBlock, for every incoming DPoS
+// block) and when building fork evidence (generateBlockEvidence), so a node
+// rejects an unaggregated or forged confirm as soon as it arrives instead of
+// only noticing once a conflicting block forces a fork check.
+func (b *blockCurrent) verifyConfirm(confirm *types.DPosProposalVoteSlot, height uint32) error {
+	if confirm.Aggregated {
+		return b.verifyAggregateConfirm(confirm)
+	}
+	if height >= b.cfg.AggregateConfirmActivateHeight {
+		return errors.New("confirm for height after the activation height must be aggregated")
+	}
+	return nil
+}
+
+// verifyAggregateConfirm checks the confirm's single BLS aggregate signature
+// against every signer named in its bitmap, replacing the per-vote signature
+// checks the legacy confirm format required. Each signer's proof of
+// possession is checked via cfg.AdmittedKeys before it is allowed to
+// contribute to the aggregate, closing the rogue-public-key gap
+// bls.AggregateVerify cannot close on its own; AdmittedKeys caches a
+// successful check per key so a signer already admitted by an earlier
+// confirm isn't re-verified on every confirm it contributes to afterward.
+func (b *blockCurrent) verifyAggregateConfirm(confirm *types.DPosProposalVoteSlot) error {
+	signers, err := b.getAggregateConfirmSigners(confirm)
+	if err != nil {
+		return err
+	}
+	if len(signers) == 0 {
+		return errors.New("aggregate confirm has no signers")
+	}
+
+	message := confirm.Proposal.Data()
+	pubs := make([]*bls.PublicKey, 0, len(signers))
+	messages := make([][]byte, 0, len(signers))
+	for _, s := range signers {
+		pub, err := bls.PublicKeyFromBytes(s)
+		if err != nil {
+			return err
+		}
+
+		popBytes := b.cfg.Arbitrators.GetArbiterProofOfPossession(s)
+		if len(popBytes) == 0 {
+			return errors.New("aggregate confirm signer has no registered proof of possession")
+		}
+		pop, err := bls.SignatureFromBytes(popBytes)
+		if err != nil {
+			return err
+		}
+		if err := b.cfg.AdmittedKeys.Admit(pub, pop); err != nil {
+			return err
+		}
+
+		pubs = append(pubs, pub)
+		messages = append(messages, message)
+	}
+
+	sig, err := bls.SignatureFromBytes(confirm.AggregateSignature)
+	if err != nil {
+		return err
+	}
+
+	ok, err := bls.AggregateVerify(pubs, messages, sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid aggregate confirm signature")
+	}
+	return nil
+}
+
 func (b *blockCurrent) GetProducersDesc() ([][]byte, error) {
 	producersInfo := b.cfg.ChainStore.GetRegisteredProducers()
 	if uint32(len(producersInfo)) < config.Parameters.ArbiterConfiguration.NormalArbitratorsCount {
@@ -149,40 +358,80 @@ func (b *blockCurrent) GetProducersDesc() ([][]byte, error) {
 	return result, nil
 }
 
+// AddDposBlock verifies the block's confirm, if any, before buffering the
+// block in the DPoS mempool. This is the normal confirm-acceptance path: it
+// runs for every incoming DPoS block, not just the ones a later fork check
+// happens to touch, so an unaggregated or forged confirm is rejected on
+// arrival instead of only being noticed during fork/illegal-block detection.
+// It also feeds the confirm's votes into the equivocation tracker via
+// observeConfirmVotes, so a byzantine arbiter is caught contradicting itself
+// as soon as its second conflicting confirm is accepted, not only once a
+// conflicting block forces a fork check.
 func (b *blockCurrent) AddDposBlock(dposBlock *types.DposBlock) (bool, bool, error) {
+	if dposBlock.Confirm != nil {
+		if err := b.verifyConfirm(dposBlock.Confirm, dposBlock.Block.Height); err != nil {
+			return false, false, err
+		}
+		if err := b.observeConfirmVotes(dposBlock.Confirm, dposBlock.Block.Height); err != nil {
+			return false, false, err
+		}
+	}
 	return b.cfg.BlockMemPool.AppendDposBlock(dposBlock)
 }
 
 func (b *blockCurrent) AssignCoinbaseTxRewards(block *types.Block, totalReward common.Fixed64) error {
-	rewardCyberRepublic := common.Fixed64(math.Ceil(float64(totalReward) * 0.3))
-	rewardDposArbiter := common.Fixed64(float64(totalReward) * 0.35)
+	schedule := b.cfg.GetActiveRewardSchedule(block.Height)
+
+	rewardCyberRepublic := common.Fixed64(math.Ceil(float64(totalReward) * schedule.CyberRepublicRatio))
+	rewardDposArbiter := common.Fixed64(float64(totalReward) * schedule.DposArbiterRatio)
+	rewardBurn := common.Fixed64(float64(totalReward) * schedule.BurnRatio)
 
 	var dposChange common.Fixed64
 	var err error
-	if dposChange, err = b.distributeDposReward(block.Transactions[0], rewardDposArbiter); err != nil {
+	if dposChange, err = b.distributeDposReward(block.Transactions[0], rewardDposArbiter, schedule); err != nil {
 		return err
 	}
-	rewardMergeMiner := common.Fixed64(totalReward) - rewardCyberRepublic - rewardDposArbiter + dposChange
+	rewardMergeMiner := common.Fixed64(totalReward) - rewardCyberRepublic - rewardDposArbiter - rewardBurn + dposChange
 	block.Transactions[0].Outputs[0].Value = rewardCyberRepublic
 	block.Transactions[0].Outputs[1].Value = rewardMergeMiner
+
+	if rewardBurn > 0 {
+		block.Transactions[0].Outputs = append(block.Transactions[0].Outputs, &types.Output{
+			AssetID:       config.ELAAssetID,
+			Value:         rewardBurn,
+			ProgramHash:   schedule.BurnAddress,
+			OutputType:    types.BurnOutput,
+			OutputPayload: &outputpayload.DefaultOutput{},
+		})
+	}
+
 	return nil
 }
 
-func (b *blockCurrent) distributeDposReward(coinBaseTx *types.Transaction, reward common.Fixed64) (common.Fixed64, error) {
+func (b *blockCurrent) distributeDposReward(coinBaseTx *types.Transaction, reward common.Fixed64,
+	schedule verconf.RewardSchedule) (common.Fixed64, error) {
 	arbitratorsHashes := b.cfg.Arbitrators.GetArbitratorsProgramHashes()
 	if uint32(len(arbitratorsHashes)) < blockchain.DefaultLedger.Arbitrators.GetArbitersCount() {
 		return 0, errors.New("current arbitrators count less than required arbitrators count")
 	}
 	candidatesHashes := b.cfg.Arbitrators.GetCandidatesProgramHashes()
 
-	totalBlockConfirmReward := float64(reward) * 0.25
-	totalTopProducersReward := float64(reward) * 0.75
-	individualBlockConfirmReward := common.Fixed64(math.Floor(totalBlockConfirmReward / float64(len(arbitratorsHashes))))
-	individualProducerReward := common.Fixed64(math.Floor(totalTopProducersReward / float64(len(arbitratorsHashes)+len(candidatesHashes))))
+	if schedule.RewardMode == verconf.RewardModeWeighted {
+		return b.distributeDposRewardWeighted(coinBaseTx, reward, schedule, arbitratorsHashes, candidatesHashes)
+	}
+	return b.distributeDposRewardEqual(coinBaseTx, reward, schedule, arbitratorsHashes, candidatesHashes)
+}
 
-	realDposReward := common.Fixed64(0)
-	for _, v := range arbitratorsHashes {
+func (b *blockCurrent) distributeDposRewardEqual(coinBaseTx *types.Transaction, reward common.Fixed64,
+	schedule verconf.RewardSchedule, arbitratorsHashes, candidatesHashes []*common.Uint168) (common.Fixed64, error) {
+	arbiterShare, individualProducerReward, change := computeEqualDposShares(
+		reward, len(arbitratorsHashes), len(candidatesHashes), schedule.BlockConfirmRatio, schedule.TopProducerRatio)
+	if change < 0 {
+		return 0, errors.New("real dpos reward more than reward limit")
+	}
+	individualBlockConfirmReward := arbiterShare - individualProducerReward
 
+	for _, v := range arbitratorsHashes {
 		coinBaseTx.Outputs = append(coinBaseTx.Outputs, &types.Output{
 			AssetID:       config.ELAAssetID,
 			Value:         individualBlockConfirmReward + individualProducerReward,
@@ -190,12 +439,9 @@ func (b *blockCurrent) distributeDposReward(coinBaseTx *types.Transaction, rewar
 			OutputType:    types.DefaultOutput,
 			OutputPayload: &outputpayload.DefaultOutput{},
 		})
-
-		realDposReward += individualBlockConfirmReward + individualProducerReward
 	}
 
 	for _, v := range candidatesHashes {
-
 		coinBaseTx.Outputs = append(coinBaseTx.Outputs, &types.Output{
 			AssetID:       config.ELAAssetID,
 			Value:         individualProducerReward,
@@ -203,15 +449,118 @@ func (b *blockCurrent) distributeDposReward(coinBaseTx *types.Transaction, rewar
 			OutputType:    types.DefaultOutput,
 			OutputPayload: &outputpayload.DefaultOutput{},
 		})
+	}
 
-		realDposReward += individualProducerReward
+	return change, nil
+}
+
+// computeEqualDposShares splits reward evenly: arbiters get a share of both
+// the block-confirm pool and the top-producer pool, candidates get a share
+// of the top-producer pool only. It returns the per-arbiter reward, the
+// per-candidate reward, and the rounding change left over after flooring
+// both individual shares.
+func computeEqualDposShares(reward common.Fixed64, arbiterCount, candidateCount int,
+	blockConfirmRatio, topProducerRatio float64) (arbiterShare, candidateShare, change common.Fixed64) {
+	totalBlockConfirmReward := float64(reward) * blockConfirmRatio
+	totalTopProducersReward := float64(reward) * topProducerRatio
+	individualBlockConfirmReward := common.Fixed64(math.Floor(totalBlockConfirmReward / float64(arbiterCount)))
+	individualProducerReward := common.Fixed64(math.Floor(totalTopProducersReward / float64(arbiterCount+candidateCount)))
+
+	realDposReward := common.Fixed64(arbiterCount)*(individualBlockConfirmReward+individualProducerReward) +
+		common.Fixed64(candidateCount)*individualProducerReward
+
+	return individualBlockConfirmReward + individualProducerReward, individualProducerReward, reward - realDposReward
+}
+
+// distributeDposRewardWeighted pays each arbiter/candidate proportionally to
+// their staked votes instead of splitting the reward evenly, with a
+// configurable floor so small candidates stay viable.
+func (b *blockCurrent) distributeDposRewardWeighted(coinBaseTx *types.Transaction, reward common.Fixed64,
+	schedule verconf.RewardSchedule, arbitratorsHashes, candidatesHashes []*common.Uint168) (common.Fixed64, error) {
+	hashes := append(append([]*common.Uint168{}, arbitratorsHashes...), candidatesHashes...)
+	votes := b.cfg.Arbitrators.GetProducerVotes(hashes)
+	if len(votes) != len(hashes) {
+		return 0, errors.New("producer vote count does not match producer hash count")
+	}
+
+	totalVotes := common.Fixed64(0)
+	for _, v := range votes {
+		totalVotes += v
+	}
+	if totalVotes == 0 {
+		return b.distributeDposRewardEqual(coinBaseTx, reward, schedule, arbitratorsHashes, candidatesHashes)
+	}
+
+	shares, err := computeWeightedDposShares(reward, votes, schedule.WeightedRewardFloorRatio, len(arbitratorsHashes))
+	if err != nil {
+		return 0, err
+	}
+
+	for i, v := range hashes {
+		coinBaseTx.Outputs = append(coinBaseTx.Outputs, &types.Output{
+			AssetID:       config.ELAAssetID,
+			Value:         shares[i],
+			ProgramHash:   *v,
+			OutputType:    types.DefaultOutput,
+			OutputPayload: &outputpayload.DefaultOutput{},
+		})
+	}
+
+	return 0, nil
+}
+
+// computeWeightedDposShares splits reward across votes proportionally to
+// each producer's stake. A per-producer floor is funded out of the reward
+// pool itself before the proportional split runs, so sum(shares) can never
+// exceed reward regardless of how skewed votes are or how high floorRatio
+// is set: the old approach of flooring a proportional share up after the
+// fact could push the total past reward and fail block assembly. votes must
+// list the arbiterCount arbiters before any candidates, matching
+// arbitratorsHashes/candidatesHashes concatenation order: the rounding
+// remainder left after flooring every share goes to the highest-voted
+// arbiter specifically, never a candidate, since candidates are not
+// guaranteed to stay in the active set the way arbiters are.
+func computeWeightedDposShares(reward common.Fixed64, votes []common.Fixed64, floorRatio float64,
+	arbiterCount int) ([]common.Fixed64, error) {
+	n := len(votes)
+	if arbiterCount <= 0 || arbiterCount > n {
+		return nil, errors.New("arbiter count out of range for weighted dpos shares")
+	}
+	totalVotes := common.Fixed64(0)
+	for _, v := range votes {
+		totalVotes += v
+	}
+	if totalVotes == 0 {
+		return nil, errors.New("no staked votes to weight the reward by")
+	}
+
+	if floorRatio < 0 {
+		floorRatio = 0
+	} else if floorRatio > 1 {
+		floorRatio = 1
+	}
+
+	floorShare := common.Fixed64(math.Floor(float64(reward) * floorRatio / float64(n)))
+	remaining := reward - floorShare*common.Fixed64(n)
+
+	shares := make([]common.Fixed64, n)
+	realDposReward := common.Fixed64(0)
+	topArbiterIndex := 0
+	for i, v := range votes {
+		if i < arbiterCount && v > votes[topArbiterIndex] {
+			topArbiterIndex = i
+		}
+		shares[i] = floorShare + common.Fixed64(math.Floor(float64(remaining)*float64(v)/float64(totalVotes)))
+		realDposReward += shares[i]
 	}
 
 	change := reward - realDposReward
 	if change < 0 {
-		return 0, errors.New("Real dpos reward more than reward limit.")
+		return nil, errors.New("weighted dpos reward exceeds reward limit")
 	}
-	return change, nil
+	shares[topArbiterIndex] += change
+
+	return shares, nil
 }
 
 func NewBlockCurrent(cfg *verconf.Config) *blockCurrent {
@@ -0,0 +1,199 @@
+package blocks
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math"
+
+	"github.com/elastos/Elastos.ELA/blockchain"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/version/verconf"
+)
+
+// Ensure blockPayouts implement the BlockVersion interface.
+var _ BlockVersion = (*blockPayouts)(nil)
+
+// blockPayouts is the block version that moves DPoS arbiter/candidate
+// rewards off the coinbase transaction and onto a dedicated header.Payouts
+// list, so the coinbase no longer grows by one output per arbiter and
+// candidate. It reuses every blockCurrent behaviour except coinbase reward
+// assignment.
+type blockPayouts struct {
+	*blockCurrent
+}
+
+func (b *blockPayouts) GetVersion() uint32 {
+	return 2
+}
+
+// AssignCoinbaseTxRewards leaves the coinbase with the Cyber Republic and
+// miner outputs, plus a BurnOutput when the active schedule's burn reward is
+// non-zero, and writes the DPoS arbiter/candidate payouts to
+// block.Header.Payouts instead of appending coinbase outputs for them.
+func (b *blockPayouts) AssignCoinbaseTxRewards(block *types.Block, totalReward common.Fixed64) error {
+	schedule := b.cfg.GetActiveRewardSchedule(block.Height)
+
+	rewardCyberRepublic := common.Fixed64(math.Ceil(float64(totalReward) * schedule.CyberRepublicRatio))
+	rewardDposArbiter := common.Fixed64(float64(totalReward) * schedule.DposArbiterRatio)
+	rewardBurn := common.Fixed64(float64(totalReward) * schedule.BurnRatio)
+
+	payouts, dposChange, err := b.buildDposPayouts(rewardDposArbiter, schedule)
+	if err != nil {
+		return err
+	}
+
+	rewardMergeMiner := common.Fixed64(totalReward) - rewardCyberRepublic - rewardDposArbiter - rewardBurn + dposChange
+	block.Transactions[0].Outputs[0].Value = rewardCyberRepublic
+	block.Transactions[0].Outputs[1].Value = rewardMergeMiner
+
+	if rewardBurn > 0 {
+		block.Transactions[0].Outputs = append(block.Transactions[0].Outputs, &types.Output{
+			AssetID:       config.ELAAssetID,
+			Value:         rewardBurn,
+			ProgramHash:   schedule.BurnAddress,
+			OutputType:    types.BurnOutput,
+			OutputPayload: &outputpayload.DefaultOutput{},
+		})
+	}
+
+	block.Header.Payouts = payouts
+	block.Header.PayoutsRoot = payoutsMerkleRoot(payouts)
+	return nil
+}
+
+// buildDposPayouts computes one Payout per arbiter and candidate for the
+// header, honoring schedule.RewardMode exactly like
+// blockCurrent.distributeDposReward does for coinbase outputs: an equal
+// split by default, or a vote-weighted split once RewardModeWeighted is
+// active. It also applies the same minimum-arbiter-count guard
+// distributeDposReward does, so a blockPayouts-version block can't be
+// assembled with fewer registered arbitrators than the chain requires.
+func (b *blockPayouts) buildDposPayouts(reward common.Fixed64,
+	schedule verconf.RewardSchedule) ([]types.Payout, common.Fixed64, error) {
+	arbitratorsHashes := b.cfg.Arbitrators.GetArbitratorsProgramHashes()
+	if uint32(len(arbitratorsHashes)) < blockchain.DefaultLedger.Arbitrators.GetArbitersCount() {
+		return nil, 0, errors.New("current arbitrators count less than required arbitrators count")
+	}
+	candidatesHashes := b.cfg.Arbitrators.GetCandidatesProgramHashes()
+
+	if schedule.RewardMode == verconf.RewardModeWeighted {
+		return b.buildDposPayoutsWeighted(reward, schedule, arbitratorsHashes, candidatesHashes)
+	}
+	return b.buildDposPayoutsEqual(reward, schedule, arbitratorsHashes, candidatesHashes)
+}
+
+// buildDposPayoutsEqual is the header-payouts equivalent of
+// blockCurrent.distributeDposRewardEqual, using the same
+// computeEqualDposShares rounding but returning Payouts instead of
+// appending coinbase outputs.
+func (b *blockPayouts) buildDposPayoutsEqual(reward common.Fixed64, schedule verconf.RewardSchedule,
+	arbitratorsHashes, candidatesHashes []*common.Uint168) ([]types.Payout, common.Fixed64, error) {
+	arbiterShare, candidateShare, change := computeEqualDposShares(
+		reward, len(arbitratorsHashes), len(candidatesHashes), schedule.BlockConfirmRatio, schedule.TopProducerRatio)
+	if change < 0 {
+		return nil, 0, errors.New("real dpos reward more than reward limit")
+	}
+
+	payouts := make([]types.Payout, 0, len(arbitratorsHashes)+len(candidatesHashes))
+	for _, v := range arbitratorsHashes {
+		payouts = append(payouts, types.Payout{ProgramHash: *v, Value: arbiterShare})
+	}
+	for _, v := range candidatesHashes {
+		payouts = append(payouts, types.Payout{ProgramHash: *v, Value: candidateShare})
+	}
+
+	return payouts, change, nil
+}
+
+// buildDposPayoutsWeighted is the header-payouts equivalent of
+// blockCurrent.distributeDposRewardWeighted: it pays each arbiter/candidate
+// proportionally to its staked votes, falling back to an equal split when
+// nobody has staked votes yet.
+func (b *blockPayouts) buildDposPayoutsWeighted(reward common.Fixed64, schedule verconf.RewardSchedule,
+	arbitratorsHashes, candidatesHashes []*common.Uint168) ([]types.Payout, common.Fixed64, error) {
+	hashes := append(append([]*common.Uint168{}, arbitratorsHashes...), candidatesHashes...)
+	votes := b.cfg.Arbitrators.GetProducerVotes(hashes)
+	if len(votes) != len(hashes) {
+		return nil, 0, errors.New("producer vote count does not match producer hash count")
+	}
+
+	totalVotes := common.Fixed64(0)
+	for _, v := range votes {
+		totalVotes += v
+	}
+	if totalVotes == 0 {
+		return b.buildDposPayoutsEqual(reward, schedule, arbitratorsHashes, candidatesHashes)
+	}
+
+	shares, err := computeWeightedDposShares(reward, votes, schedule.WeightedRewardFloorRatio, len(arbitratorsHashes))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	payouts := make([]types.Payout, len(hashes))
+	for i, v := range hashes {
+		payouts[i] = types.Payout{ProgramHash: *v, Value: shares[i]}
+	}
+
+	return payouts, 0, nil
+}
+
+// CreditPayouts credits every payout in the block's header directly to its
+// recipient's balance. Unlike blockCurrent's coinbase-output rewards, a
+// header payout never appears as a spendable output, so the chain's
+// block-acceptance path must call this once a blockPayouts-version block is
+// accepted or the reward is simply lost.
+func (b *blockPayouts) CreditPayouts(block *types.Block) error {
+	for _, p := range block.Header.Payouts {
+		if err := b.cfg.StateApplier.CreditBalance(p.ProgramHash, p.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBlockPayouts returns the payouts recorded in a block's header, the data
+// a getblockpayouts RPC would need to serve since they no longer appear
+// among the coinbase transaction's outputs; no such RPC is registered in
+// this package.
+func (b *blockPayouts) GetBlockPayouts(hash common.Uint256) ([]types.Payout, error) {
+	block, err := b.cfg.ChainStore.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header.Payouts, nil
+}
+
+// payoutsMerkleRoot hashes the serialized payouts into a merkle root so
+// light clients can prove an individual payout without the full header.
+func payoutsMerkleRoot(payouts []types.Payout) common.Uint256 {
+	hashes := make([]common.Uint256, 0, len(payouts))
+	for _, p := range payouts {
+		buf := make([]byte, 0, 8+len(p.ProgramHash))
+		buf = append(buf, p.ProgramHash[:]...)
+		buf = append(buf, common.Fixed64(p.Value).Bytes()...)
+		hashes = append(hashes, common.Uint256(sha256.Sum256(buf)))
+	}
+	return common.ComputeMerkleRoot(hashes)
+}
+
+// NewBlockPayouts creates the payouts-on-header block version.
+func NewBlockPayouts(cfg *verconf.Config) *blockPayouts {
+	return &blockPayouts{blockCurrent: NewBlockCurrent(cfg)}
+}
+
+// SelectBlockVersion returns the BlockVersion a block at height should use:
+// blockPayouts once height reaches cfg.PayoutsActivateHeight, blockCurrent
+// before it. Callers assembling or validating a block must pick the version
+// this way rather than hardcoding blockCurrent, or a block at or after the
+// activation height will keep paying DPoS rewards through coinbase outputs
+// instead of header.Payouts.
+func SelectBlockVersion(cfg *verconf.Config, height uint32) BlockVersion {
+	if height >= cfg.PayoutsActivateHeight {
+		return NewBlockPayouts(cfg)
+	}
+	return NewBlockCurrent(cfg)
+}
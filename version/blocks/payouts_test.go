@@ -0,0 +1,208 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/version/verconf"
+)
+
+// fakeArbitrators is a minimal verconf.Arbitrators for testing reward
+// distribution without a real arbitrators state machine.
+type fakeArbitrators struct {
+	arbiterHashes   []*common.Uint168
+	candidateHashes []*common.Uint168
+	votes           map[common.Uint168]common.Fixed64
+}
+
+func (f *fakeArbitrators) GetArbitrators() [][]byte { return nil }
+
+func (f *fakeArbitrators) GetArbitratorsProgramHashes() []*common.Uint168 {
+	return f.arbiterHashes
+}
+
+func (f *fakeArbitrators) GetCandidatesProgramHashes() []*common.Uint168 {
+	return f.candidateHashes
+}
+
+func (f *fakeArbitrators) GetProducerVotes(hashes []*common.Uint168) []common.Fixed64 {
+	votes := make([]common.Fixed64, len(hashes))
+	for i, h := range hashes {
+		votes[i] = f.votes[*h]
+	}
+	return votes
+}
+
+func (f *fakeArbitrators) GetArbiterProofOfPossession(pubkey []byte) []byte { return nil }
+
+// TestBuildDposPayoutsSumsToReward checks the header-payouts path keeps the
+// same sum(payouts)+change == reward invariant the coinbase-output path
+// requires.
+func TestBuildDposPayoutsSumsToReward(t *testing.T) {
+	arbiters := []*common.Uint168{{0x01}, {0x02}, {0x03}}
+	candidates := []*common.Uint168{{0x04}, {0x05}}
+
+	cfg := &verconf.Config{
+		Arbitrators: &fakeArbitrators{arbiterHashes: arbiters, candidateHashes: candidates},
+	}
+	b := &blockPayouts{blockCurrent: NewBlockCurrent(cfg)}
+	schedule := verconf.RewardSchedule{BlockConfirmRatio: 0.25, TopProducerRatio: 0.75}
+	reward := common.Fixed64(100000)
+
+	payouts, change, err := b.buildDposPayouts(reward, schedule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(payouts) != len(arbiters)+len(candidates) {
+		t.Fatalf("got %d payouts, want %d", len(payouts), len(arbiters)+len(candidates))
+	}
+
+	sum := change
+	for _, p := range payouts {
+		sum += p.Value
+	}
+	if sum != reward {
+		t.Fatalf("sum(payouts)+change = %v, want reward %v", sum, reward)
+	}
+}
+
+// TestBuildDposPayoutsMatchesLegacyCoinbaseSplit pins the migration
+// invariant behind the blockPayouts version: moving rewards from coinbase
+// outputs to header.Payouts must not change how much each arbiter and
+// candidate is paid versus blockCurrent's pre-migration coinbase split, only
+// where the payout is recorded.
+func TestBuildDposPayoutsMatchesLegacyCoinbaseSplit(t *testing.T) {
+	arbiters := []*common.Uint168{{0x01}, {0x02}, {0x03}}
+	candidates := []*common.Uint168{{0x04}, {0x05}}
+	arbitrators := &fakeArbitrators{arbiterHashes: arbiters, candidateHashes: candidates}
+	cfg := &verconf.Config{Arbitrators: arbitrators}
+	schedule := verconf.RewardSchedule{BlockConfirmRatio: 0.25, TopProducerRatio: 0.75}
+	reward := common.Fixed64(100000)
+
+	legacy := NewBlockCurrent(cfg)
+	coinBaseTx := &types.Transaction{}
+	legacyChange, err := legacy.distributeDposRewardEqual(coinBaseTx, reward, schedule, arbiters, candidates)
+	if err != nil {
+		t.Fatalf("unexpected error from legacy split: %v", err)
+	}
+
+	migrated := &blockPayouts{blockCurrent: NewBlockCurrent(cfg)}
+	payouts, migratedChange, err := migrated.buildDposPayouts(reward, schedule)
+	if err != nil {
+		t.Fatalf("unexpected error from payouts split: %v", err)
+	}
+
+	if migratedChange != legacyChange {
+		t.Fatalf("payouts change %v != legacy coinbase change %v", migratedChange, legacyChange)
+	}
+	if len(payouts) != len(coinBaseTx.Outputs) {
+		t.Fatalf("got %d payouts, want %d to match legacy coinbase outputs", len(payouts), len(coinBaseTx.Outputs))
+	}
+	for i, payout := range payouts {
+		if payout.Value != coinBaseTx.Outputs[i].Value || payout.ProgramHash != coinBaseTx.Outputs[i].ProgramHash {
+			t.Fatalf("payout %d = %+v, want to match legacy coinbase output %+v", i, payout, coinBaseTx.Outputs[i])
+		}
+	}
+}
+
+// TestBuildDposPayoutsWeightedMatchesLegacyCoinbaseSplit pins the same
+// migration invariant as TestBuildDposPayoutsMatchesLegacyCoinbaseSplit for
+// RewardModeWeighted, which buildDposPayouts must honor rather than always
+// falling back to the equal split.
+func TestBuildDposPayoutsWeightedMatchesLegacyCoinbaseSplit(t *testing.T) {
+	arbiters := []*common.Uint168{{0x01}, {0x02}, {0x03}}
+	candidates := []*common.Uint168{{0x04}, {0x05}}
+	arbitrators := &fakeArbitrators{
+		arbiterHashes:   arbiters,
+		candidateHashes: candidates,
+		votes: map[common.Uint168]common.Fixed64{
+			{0x01}: 100, {0x02}: 50, {0x03}: 10, {0x04}: 5, {0x05}: 1,
+		},
+	}
+	cfg := &verconf.Config{Arbitrators: arbitrators}
+	schedule := verconf.RewardSchedule{RewardMode: verconf.RewardModeWeighted, WeightedRewardFloorRatio: 0.1}
+	reward := common.Fixed64(100000)
+
+	legacy := NewBlockCurrent(cfg)
+	coinBaseTx := &types.Transaction{}
+	legacyChange, err := legacy.distributeDposRewardWeighted(coinBaseTx, reward, schedule, arbiters, candidates)
+	if err != nil {
+		t.Fatalf("unexpected error from legacy split: %v", err)
+	}
+
+	migrated := &blockPayouts{blockCurrent: NewBlockCurrent(cfg)}
+	payouts, migratedChange, err := migrated.buildDposPayouts(reward, schedule)
+	if err != nil {
+		t.Fatalf("unexpected error from payouts split: %v", err)
+	}
+
+	if migratedChange != legacyChange {
+		t.Fatalf("payouts change %v != legacy coinbase change %v", migratedChange, legacyChange)
+	}
+	if len(payouts) != len(coinBaseTx.Outputs) {
+		t.Fatalf("got %d payouts, want %d to match legacy coinbase outputs", len(payouts), len(coinBaseTx.Outputs))
+	}
+	for i, payout := range payouts {
+		if payout.Value != coinBaseTx.Outputs[i].Value || payout.ProgramHash != coinBaseTx.Outputs[i].ProgramHash {
+			t.Fatalf("payout %d = %+v, want to match legacy coinbase output %+v", i, payout, coinBaseTx.Outputs[i])
+		}
+	}
+}
+
+// TestSelectBlockVersionSwitchesAtActivationHeight is the migration test at
+// the activation height: a block immediately before PayoutsActivateHeight
+// must keep paying DPoS rewards through coinbase outputs, while a block at
+// or after it must record them in header.Payouts instead.
+func TestSelectBlockVersionSwitchesAtActivationHeight(t *testing.T) {
+	arbiters := []*common.Uint168{{0x01}, {0x02}}
+	cfg := &verconf.Config{
+		Arbitrators:           &fakeArbitrators{arbiterHashes: arbiters},
+		PayoutsActivateHeight: 1000,
+	}
+	schedule := verconf.RewardSchedule{
+		CyberRepublicRatio: 0.3, DposArbiterRatio: 0.35,
+		BlockConfirmRatio: 0.25, TopProducerRatio: 0.75,
+	}
+	cfg.RewardSchedules = []verconf.RewardSchedule{schedule}
+
+	newCoinbaseBlock := func(height uint32) *types.Block {
+		return &types.Block{
+			Header:       types.Header{Height: height},
+			Transactions: []*types.Transaction{{Outputs: []*types.Output{{}, {}}}},
+		}
+	}
+
+	before := SelectBlockVersion(cfg, 999)
+	if _, ok := before.(*blockPayouts); ok {
+		t.Fatal("height 999 selected blockPayouts, want blockCurrent before activation")
+	}
+	blockBefore := newCoinbaseBlock(999)
+	if err := before.AssignCoinbaseTxRewards(blockBefore, 100000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blockBefore.Transactions[0].Outputs) <= 2 {
+		t.Fatalf("pre-activation block should grow the coinbase with per-producer outputs, got %d outputs",
+			len(blockBefore.Transactions[0].Outputs))
+	}
+	if len(blockBefore.Header.Payouts) != 0 {
+		t.Fatalf("pre-activation block should not populate header.Payouts, got %d", len(blockBefore.Header.Payouts))
+	}
+
+	at := SelectBlockVersion(cfg, 1000)
+	if _, ok := at.(*blockPayouts); !ok {
+		t.Fatalf("height 1000 selected %T, want blockPayouts at the activation height", at)
+	}
+	blockAt := newCoinbaseBlock(1000)
+	if err := at.AssignCoinbaseTxRewards(blockAt, 100000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blockAt.Transactions[0].Outputs) != 2 {
+		t.Fatalf("post-activation block should leave only the cyber republic + miner coinbase outputs, got %d",
+			len(blockAt.Transactions[0].Outputs))
+	}
+	if len(blockAt.Header.Payouts) != len(arbiters) {
+		t.Fatalf("post-activation block should record %d payouts in the header, got %d",
+			len(arbiters), len(blockAt.Header.Payouts))
+	}
+}
@@ -0,0 +1,93 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+func TestComputeEqualDposSharesSumsToReward(t *testing.T) {
+	cases := []struct {
+		reward                           common.Fixed64
+		arbiterCount, candidateCount     int
+		blockConfirmRatio, producerRatio float64
+	}{
+		{reward: 100000, arbiterCount: 5, candidateCount: 3, blockConfirmRatio: 0.25, producerRatio: 0.75},
+		{reward: 1, arbiterCount: 7, candidateCount: 2, blockConfirmRatio: 0.25, producerRatio: 0.75},
+		{reward: 123456789, arbiterCount: 12, candidateCount: 30, blockConfirmRatio: 0.4, producerRatio: 0.6},
+	}
+
+	for _, c := range cases {
+		arbiterShare, candidateShare, change := computeEqualDposShares(
+			c.reward, c.arbiterCount, c.candidateCount, c.blockConfirmRatio, c.producerRatio)
+		if change < 0 {
+			t.Fatalf("negative change for case %+v: %v", c, change)
+		}
+
+		sum := common.Fixed64(c.arbiterCount)*arbiterShare + common.Fixed64(c.candidateCount)*candidateShare + change
+		if sum != c.reward {
+			t.Fatalf("equal split sum(outputs)+change = %v, want reward %v (case %+v)", sum, c.reward, c)
+		}
+	}
+}
+
+func TestComputeWeightedDposSharesSumsToReward(t *testing.T) {
+	cases := []struct {
+		name         string
+		reward       common.Fixed64
+		votes        []common.Fixed64
+		floorRatio   float64
+		arbiterCount int
+	}{
+		{name: "even votes, no floor", reward: 100000, votes: []common.Fixed64{10, 10, 10, 10}, floorRatio: 0, arbiterCount: 4},
+		{name: "whale plus many small, with floor", reward: 100000,
+			votes: []common.Fixed64{1000000, 1, 1, 1, 1, 1, 1, 1, 1, 1}, floorRatio: 0.3, arbiterCount: 3},
+		{name: "floor ratio at the cap", reward: 54321,
+			votes: []common.Fixed64{5, 1, 1}, floorRatio: 1, arbiterCount: 2},
+		{name: "floor ratio above the cap is clamped", reward: 54321,
+			votes: []common.Fixed64{5, 1, 1}, floorRatio: 5, arbiterCount: 2},
+	}
+
+	for _, c := range cases {
+		shares, err := computeWeightedDposShares(c.reward, c.votes, c.floorRatio, c.arbiterCount)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.name, err)
+		}
+
+		sum := common.Fixed64(0)
+		for _, s := range shares {
+			if s < 0 {
+				t.Fatalf("%s: negative share %v", c.name, s)
+			}
+			sum += s
+		}
+		if sum != c.reward {
+			t.Fatalf("%s: sum(shares) = %v, want reward %v", c.name, sum, c.reward)
+		}
+	}
+}
+
+// TestComputeWeightedDposSharesRemainderStaysWithArbiters pins the highest
+// vote overall on a candidate, past arbiterCount: the flooring remainder
+// must still land on the top-voted arbiter, not that candidate.
+func TestComputeWeightedDposSharesRemainderStaysWithArbiters(t *testing.T) {
+	// votes[2] (a candidate) has the most votes overall, but with
+	// arbiterCount 2 only votes[0] and votes[1] (both arbiters) are
+	// eligible for the remainder.
+	votes := []common.Fixed64{1, 1, 6}
+	arbiterCount := 2
+
+	shares, err := computeWeightedDposShares(10, votes, 0, arbiterCount)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shares[2] != 7 {
+		t.Fatalf("candidate share = %v, want 7 (no remainder)", shares[2])
+	}
+	if shares[0] != 2 {
+		t.Fatalf("top arbiter share = %v, want 2 (floor 1 + remainder 1)", shares[0])
+	}
+	if shares[0]+shares[1]+shares[2] != 10 {
+		t.Fatalf("sum(shares) = %v, want reward 10", shares[0]+shares[1]+shares[2])
+	}
+}
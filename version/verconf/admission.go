@@ -0,0 +1,47 @@
+package verconf
+
+import (
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/crypto/bls"
+)
+
+// AdmittedKeyCache remembers which BLS public keys have already passed
+// AdmitArbiterKey's proof-of-possession check, so a confirm naming a signer
+// that was already admitted doesn't pay for re-running the pairing-based PoP
+// check on every single confirm that signer contributes to. Without this,
+// verifyAggregateConfirm would redo the same O(1)-pairing check for every
+// signer on every block, turning a one-time registration check into a
+// per-block cost.
+type AdmittedKeyCache struct {
+	mutex    sync.RWMutex
+	admitted map[string]bool
+}
+
+// NewAdmittedKeyCache creates an empty AdmittedKeyCache.
+func NewAdmittedKeyCache() *AdmittedKeyCache {
+	return &AdmittedKeyCache{admitted: make(map[string]bool)}
+}
+
+// Admit checks pub's proof of possession via AdmitArbiterKey unless pub has
+// already been admitted, caching a successful check so later calls for the
+// same key return immediately without touching the curve again.
+func (c *AdmittedKeyCache) Admit(pub *bls.PublicKey, pop *bls.Signature) error {
+	key := string(pub.Bytes())
+
+	c.mutex.RLock()
+	ok := c.admitted[key]
+	c.mutex.RUnlock()
+	if ok {
+		return nil
+	}
+
+	if err := AdmitArbiterKey(pub, pop); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.admitted[key] = true
+	c.mutex.Unlock()
+	return nil
+}
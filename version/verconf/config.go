@@ -0,0 +1,246 @@
+// Package verconf holds the collaborators and configuration the version
+// package needs to assemble and validate blocks for a given block version,
+// without importing the packages (blockchain, p2p, mempool) that own them.
+//
+// This package and version/blocks assume several additions land alongside
+// them in core/types (BurnOutput, Payout, the confirm's aggregate-signature
+// fields, the illegal-vote payload types) and in blockchain and rpc (the
+// getillegalvoteevidence/getblockpayouts RPC handlers). Those additions are
+// outside this package's scope and must be reviewed and merged separately.
+package verconf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/crypto/bls"
+	"github.com/elastos/Elastos.ELA/dpos"
+)
+
+// RewardMode selects how distributeDposReward splits the DPoS portion of
+// the coinbase reward across arbiters and candidates.
+type RewardMode string
+
+const (
+	// RewardModeEqual splits the DPoS reward evenly across every arbiter
+	// and candidate, as the chain always has.
+	RewardModeEqual RewardMode = "equal"
+	// RewardModeWeighted pays each arbiter/candidate proportionally to
+	// its staked votes, with a configurable floor.
+	RewardModeWeighted RewardMode = "weighted"
+)
+
+// RewardSchedule is the coinbase reward split, burn ratio, and reward mode
+// that applies starting at StartHeight. Config holds a list of these so
+// DPoS economics can be retuned at a governance-controlled activation
+// height without a hard fork of the block version.
+type RewardSchedule struct {
+	StartHeight              uint32         `json:"startheight"`
+	CyberRepublicRatio       float64        `json:"cyberrepublicratio"`
+	DposArbiterRatio         float64        `json:"dposarbiterratio"`
+	BlockConfirmRatio        float64        `json:"blockconfirmratio"`
+	TopProducerRatio         float64        `json:"topproducerratio"`
+	BurnRatio                float64        `json:"burnratio"`
+	BurnAddress              common.Uint168 `json:"burnaddress"`
+	RewardMode               RewardMode     `json:"rewardmode"`
+	WeightedRewardFloorRatio float64        `json:"weightedrewardfloorratio"`
+}
+
+// defaultRewardSchedule preserves the coinbase split the chain used before
+// RewardSchedule existed, so a chain with none configured for the requested
+// height behaves exactly as it always has.
+var defaultRewardSchedule = RewardSchedule{
+	CyberRepublicRatio: 0.3,
+	DposArbiterRatio:   0.35,
+	BlockConfirmRatio:  0.25,
+	TopProducerRatio:   0.75,
+	RewardMode:         RewardModeEqual,
+}
+
+// ParseRewardSchedules decodes the reward-schedule list from the node's
+// JSON config file and sorts it by StartHeight so GetActiveRewardSchedule
+// can do a simple linear scan.
+func ParseRewardSchedules(raw []byte) ([]RewardSchedule, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var schedules []RewardSchedule
+	if err := json.Unmarshal(raw, &schedules); err != nil {
+		return nil, err
+	}
+
+	for i := range schedules {
+		if err := validateRewardSchedule(&schedules[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].StartHeight < schedules[j].StartHeight
+	})
+	return schedules, nil
+}
+
+// validateRewardSchedule rejects a schedule whose ratios could drive
+// AssignCoinbaseTxRewards' merge-miner reward negative: every ratio must sit
+// within [0,1], and the three coinbase-level shares it carves out of
+// totalReward before anything else (Cyber Republic, DPoS arbiter, burn)
+// must not sum past 1.
+func validateRewardSchedule(s *RewardSchedule) error {
+	ratios := map[string]float64{
+		"cyberrepublicratio":       s.CyberRepublicRatio,
+		"dposarbiterratio":         s.DposArbiterRatio,
+		"blockconfirmratio":        s.BlockConfirmRatio,
+		"topproducerratio":         s.TopProducerRatio,
+		"burnratio":                s.BurnRatio,
+		"weightedrewardfloorratio": s.WeightedRewardFloorRatio,
+	}
+	for name, ratio := range ratios {
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("reward schedule at height %d: %s %v out of range [0,1]", s.StartHeight, name, ratio)
+		}
+	}
+
+	if sum := s.CyberRepublicRatio + s.DposArbiterRatio + s.BurnRatio; sum > 1 {
+		return fmt.Errorf("reward schedule at height %d: cyberrepublicratio+dposarbiterratio+burnratio %v exceeds 1", s.StartHeight, sum)
+	}
+
+	return nil
+}
+
+// Arbitrators is the subset of the arbitrators state machine the version
+// package needs: the active arbiter set, their coinbase program hashes, and
+// their staked votes for the weighted reward mode.
+type Arbitrators interface {
+	GetArbitrators() [][]byte
+	GetArbitratorsProgramHashes() []*common.Uint168
+	GetCandidatesProgramHashes() []*common.Uint168
+	// GetProducerVotes returns, in the same order as hashes, the votes
+	// staked on each producer. Implementations must return one entry per
+	// hash; callers treat a length mismatch as an error rather than
+	// indexing past the result.
+	GetProducerVotes(hashes []*common.Uint168) []common.Fixed64
+	// GetArbiterProofOfPossession returns the proof of possession an
+	// arbiter presented for pubkey at registration time, or nil if pubkey
+	// is not a registered arbiter. AdmitArbiterKey verifies it before a
+	// confirm signed by pubkey is ever accepted.
+	GetArbiterProofOfPossession(pubkey []byte) []byte
+}
+
+// AdmitArbiterKey checks a BLS proof of possession before a confirm signer
+// is trusted to contribute to an aggregate signature. Skipping this check
+// reopens the rogue-public-key attack bls.AggregateVerify itself cannot
+// defend against: an attacker could otherwise derive a key crafted to
+// cancel an honest arbiter's contribution to the aggregate.
+func AdmitArbiterKey(pub *bls.PublicKey, pop *bls.Signature) error {
+	ok, err := bls.PopVerify(pub, pop)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid arbiter BLS proof of possession")
+	}
+	return nil
+}
+
+// Server is the subset of the node's server the version package needs to
+// tell whether it is synced to the tip of the chain.
+type Server interface {
+	IsCurrent() bool
+}
+
+// ChainStore is the subset of the chain store the version package needs to
+// look up blocks, confirms and registered producers by height or hash.
+type ChainStore interface {
+	GetBlockHash(height uint32) (common.Uint256, error)
+	GetBlock(hash common.Uint256) (*types.Block, error)
+	GetConfirm(hash common.Uint256) (*types.DPosProposalVoteSlot, error)
+	GetRegisteredProducers() []*types.ProducerInfo
+}
+
+// TxMemPool is the subset of the transaction mempool the version package
+// needs to submit synthesized illegal-evidence transactions.
+type TxMemPool interface {
+	AppendToTxPool(tx *types.Transaction) error
+}
+
+// BlockMemPool is the subset of the DPoS block mempool the version package
+// needs to buffer blocks awaiting confirmation.
+type BlockMemPool interface {
+	AppendDposBlock(dposBlock *types.DposBlock) (bool, bool, error)
+}
+
+// BalanceApplier is the subset of the chain state machine the version
+// package needs to credit a payout directly to its recipient's balance, for
+// block versions (see blockPayouts) whose DPoS rewards aren't spendable
+// coinbase outputs and so need crediting some other way.
+type BalanceApplier interface {
+	CreditBalance(hash common.Uint168, value common.Fixed64) error
+}
+
+// Versions is the subset of the transaction versioning policy the version
+// package needs to stamp the transactions it synthesizes.
+type Versions interface {
+	GetDefaultTxVersion(height uint32) byte
+}
+
+// Config bundles every collaborator and config value a BlockVersion
+// implementation in the blocks package needs.
+type Config struct {
+	Arbitrators  Arbitrators
+	Server       Server
+	ChainStore   ChainStore
+	TxMemPool    TxMemPool
+	BlockMemPool BlockMemPool
+	Versions     Versions
+
+	// StateApplier credits blockPayouts' header payouts directly; it is
+	// unused by blockCurrent, whose DPoS rewards are ordinary coinbase
+	// outputs and need no separate crediting step.
+	StateApplier BalanceApplier
+
+	// EquivocationTracker flags a signer producing two conflicting
+	// proposals/votes at the same (height, round) before either has been
+	// finalized locally.
+	EquivocationTracker *dpos.EquivocationTracker
+
+	// RewardSchedules must be kept sorted by StartHeight ascending; use
+	// ParseRewardSchedules to load it from JSON rather than assigning it
+	// directly.
+	RewardSchedules []RewardSchedule
+
+	// AggregateConfirmActivateHeight is the height at and after which a
+	// confirm must use the compact BLS aggregate-signature encoding
+	// instead of the legacy per-vote signature list.
+	AggregateConfirmActivateHeight uint32
+
+	// AdmittedKeys caches which signer keys have already passed
+	// AdmitArbiterKey's proof-of-possession check, so verifyAggregateConfirm
+	// doesn't re-run that check for every confirm a previously-admitted
+	// signer contributes to.
+	AdmittedKeys *AdmittedKeyCache
+
+	// PayoutsActivateHeight is the height at and after which a block moves
+	// DPoS arbiter/candidate rewards from coinbase outputs onto
+	// header.Payouts (see blocks.blockPayouts).
+	PayoutsActivateHeight uint32
+}
+
+// GetActiveRewardSchedule returns the RewardSchedule with the highest
+// StartHeight not exceeding height, or the pre-RewardSchedule default split
+// if none has activated yet.
+func (c *Config) GetActiveRewardSchedule(height uint32) RewardSchedule {
+	active := defaultRewardSchedule
+	for _, s := range c.RewardSchedules {
+		if s.StartHeight > height {
+			break
+		}
+		active = s
+	}
+	return active
+}